@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/traefik/genconf/dynamic"
 )
@@ -18,12 +20,29 @@ type Client struct {
 
 	endpoint Endpoint
 	resolver *string
+
+	headers     map[string]string
+	pollTimeout time.Duration
+
+	lastHash uint64
 }
 
 const defaultRawPath = "/api/rawdata"
 
+const (
+	retryInitialInterval = 500 * time.Millisecond
+	retryMaxInterval     = 10 * time.Second
+	retryMaxElapsedTime  = time.Minute
+)
+
 var ErrEmptyResponse = errors.New("received empty response")
 
+// errNonRetryable marks httpCall failures that a retry can't fix, such as
+// a malformed response body or a 4xx from the upstream API, so
+// httpCallWithBackoff can fail fast instead of spending its whole
+// retryMaxElapsedTime budget on a permanent error.
+var errNonRetryable = errors.New("non-retryable error")
+
 func (c *Client) Endpoint() string {
 	if c == nil {
 		return "empty"
@@ -32,28 +51,145 @@ func (c *Client) Endpoint() string {
 	return c.endpoint.Host
 }
 
-func (c *Client) httpCall(ctx context.Context) (*dynamic.Configuration, error) {
+func (c *Client) httpCall(ctx context.Context) (*dynamic.Configuration, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.pollTimeout)
+	defer cancel()
+
 	uri := c.endpoint.buildURI(c.endpoint.API, defaultRawPath)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return nil, fmt.Errorf("could not prepare request for %s: %w", uri, err)
+		return nil, 0, fmt.Errorf("%w: could not prepare request for %s: %v", errNonRetryable, uri, err)
+	}
+
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
 	}
 
 	var res *http.Response
 	if res, err = c.Do(req); err != nil {
-		return nil, fmt.Errorf("could not make request for %s: %w", uri, err)
+		// Dial/transient network errors: let httpCallWithBackoff retry.
+		return nil, 0, fmt.Errorf("could not make request for %s: %w", uri, err)
+	}
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		_ = res.Body.Close()
+
+		// Transient upstream failure: let httpCallWithBackoff retry.
+		return nil, 0, fmt.Errorf("unexpected status for %s: %s", uri, res.Status)
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		_ = res.Body.Close()
+
+		return nil, 0, fmt.Errorf("%w: unexpected status for %s: %s", errNonRetryable, uri, res.Status)
 	}
 
 	buf := new(bytes.Buffer)
 	tee := io.TeeReader(res.Body, buf)
 
-	var result dynamic.Configuration
-	if err = json.NewDecoder(tee).Decode(&result.HTTP); err != nil {
-		return nil, fmt.Errorf("could not decode response for %s: %s: %w", uri, buf.String(), err)
+	var raw rawData
+	if err = json.NewDecoder(tee).Decode(&raw); err != nil {
+		return nil, 0, fmt.Errorf("%w: could not decode response for %s: %s: %v", errNonRetryable, uri, buf.String(), err)
 	}
 
-	return &result, res.Body.Close()
+	result := &dynamic.Configuration{
+		HTTP: &raw.HTTPConfiguration,
+		TCP: &dynamic.TCPConfiguration{
+			Routers:     raw.TCPRouters,
+			Services:    raw.TCPServices,
+			Middlewares: raw.TCPMiddlewares,
+		},
+		UDP: &dynamic.UDPConfiguration{
+			Routers:  raw.UDPRouters,
+			Services: raw.UDPServices,
+		},
+	}
+
+	sum := fnv.New64a()
+	_, _ = sum.Write(buf.Bytes())
+
+	return result, sum.Sum64(), res.Body.Close()
+}
+
+// httpCallWithBackoff retries httpCall with an exponential backoff so a
+// transient 5xx or dial error doesn't immediately nil-out the config
+// channel and flap routes. Errors wrapping errNonRetryable (malformed
+// bodies, 4xx) fail immediately instead of spending the retry budget.
+// It gives up once retryMaxElapsedTime has passed or ctx is done,
+// whichever comes first.
+func (c *Client) httpCallWithBackoff(ctx context.Context) (*dynamic.Configuration, uint64, error) {
+	deadline := time.Now().Add(retryMaxElapsedTime)
+	interval := retryInitialInterval
+
+	for {
+		res, hash, err := c.httpCall(ctx)
+		if err == nil {
+			return res, hash, nil
+		}
+
+		if errors.Is(err, errNonRetryable) {
+			return nil, 0, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, 0, fmt.Errorf("giving up after %s: %w", retryMaxElapsedTime, err)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, 0, ctx.Err()
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > retryMaxInterval {
+			interval = retryMaxInterval
+		}
+	}
+}
+
+// rawData mirrors the flattened shape returned by Traefik's /api/rawdata
+// endpoint, where HTTP fields sit at the top level alongside the
+// tcp/udp-prefixed counterparts.
+type rawData struct {
+	dynamic.HTTPConfiguration
+
+	TCPRouters     map[string]*dynamic.TCPRouter     `json:"tcpRouters"`
+	TCPServices    map[string]*dynamic.TCPService    `json:"tcpServices"`
+	TCPMiddlewares map[string]*dynamic.TCPMiddleware `json:"tcpMiddlewares"`
+
+	UDPRouters  map[string]*dynamic.UDPRouter  `json:"udpRouters"`
+	UDPServices map[string]*dynamic.UDPService `json:"udpServices"`
+}
+
+// cloneMiddlewares copies the dynamic.Middleware definitions referenced by
+// refs from res.HTTP.Middlewares into output.HTTP.Middlewares, renaming
+// them with the same "-<host>" suffix as routers and services so chains
+// resolve on the aggregating Traefik. It returns the rewritten refs.
+func (c *Client) cloneMiddlewares(res, output *dynamic.Configuration, refs []string) []string {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		middleware, ok := res.HTTP.Middlewares[ref]
+		if !ok {
+			continue
+		}
+
+		name := strings.Split(ref, "@")[0]
+		name = fmt.Sprintf("%s-%s", name, c.endpoint.Host)
+
+		output.HTTP.Middlewares[name] = middleware
+		names = append(names, name)
+	}
+
+	return names
 }
 
 func (c *Client) prepareResponse(res *dynamic.Configuration) *dynamic.Configuration {
@@ -67,7 +203,7 @@ func (c *Client) prepareResponse(res *dynamic.Configuration) *dynamic.Configurat
 		name = fmt.Sprintf("%s-%s", name, c.endpoint.Host)
 
 		service, ok := res.HTTP.Services[key]
-		if !ok {
+		if !ok || service.LoadBalancer == nil {
 			continue
 		}
 
@@ -80,8 +216,12 @@ func (c *Client) prepareResponse(res *dynamic.Configuration) *dynamic.Configurat
 		}
 
 		output.HTTP.Routers[name] = &dynamic.Router{
-			Service: name,
-			Rule:    item.Rule,
+			Service:     name,
+			Rule:        item.Rule,
+			EntryPoints: item.EntryPoints,
+			Priority:    item.Priority,
+			TLS:         item.TLS,
+			Middlewares: c.cloneMiddlewares(res, &output, item.Middlewares),
 		}
 
 		var servers []dynamic.Server
@@ -92,10 +232,17 @@ func (c *Client) prepareResponse(res *dynamic.Configuration) *dynamic.Configurat
 		}
 
 		output.HTTP.Services[name] = &dynamic.Service{
-			LoadBalancer: &dynamic.ServersLoadBalancer{Servers: servers},
+			LoadBalancer: &dynamic.ServersLoadBalancer{
+				Servers:        servers,
+				Sticky:         service.LoadBalancer.Sticky,
+				HealthCheck:    service.LoadBalancer.HealthCheck,
+				PassHostHeader: service.LoadBalancer.PassHostHeader,
+			},
 		}
 
 		if c.resolver != nil {
+			secureMiddlewares := output.HTTP.Routers[name].Middlewares
+
 			output.HTTP.Routers[name].Middlewares = append(
 				output.HTTP.Routers[name].Middlewares,
 				"http2https",
@@ -104,7 +251,15 @@ func (c *Client) prepareResponse(res *dynamic.Configuration) *dynamic.Configurat
 			output.HTTP.Routers[name+"-secure"] = &dynamic.Router{
 				Service: name,
 				Rule:    item.Rule,
-				TLS:     &dynamic.RouterTLSConfig{CertResolver: *c.resolver},
+				// EntryPoints is intentionally left unset here: the plain
+				// router above already carries the source router's
+				// entrypoints, and copying them onto the -secure router too
+				// would bind two routers with an identical Rule to the same
+				// entrypoint, defeating the http2https split this branch
+				// builds.
+				Priority:    item.Priority,
+				Middlewares: secureMiddlewares,
+				TLS:         &dynamic.RouterTLSConfig{CertResolver: *c.resolver},
 			}
 
 			output.HTTP.Middlewares["http2https"] = &dynamic.Middleware{
@@ -113,21 +268,118 @@ func (c *Client) prepareResponse(res *dynamic.Configuration) *dynamic.Configurat
 		}
 	}
 
+	c.prepareTCPResponse(res, &output)
+	c.prepareUDPResponse(res, &output)
+
 	return &output
 }
 
+func (c *Client) prepareTCPResponse(res, output *dynamic.Configuration) {
+	for key, item := range res.TCP.Routers {
+		if strings.HasSuffix(key, "@internal") {
+			continue
+		}
+
+		name := strings.Split(key, "@")[0]
+		name = fmt.Sprintf("%s-%s", name, c.endpoint.Host)
+
+		service, ok := res.TCP.Services[key]
+		if !ok || service.LoadBalancer == nil {
+			continue
+		}
+
+		if output.TCP == nil {
+			output.TCP = &dynamic.TCPConfiguration{
+				Routers:  make(map[string]*dynamic.TCPRouter),
+				Services: make(map[string]*dynamic.TCPService),
+			}
+		}
+
+		output.TCP.Routers[name] = &dynamic.TCPRouter{
+			Service: name,
+			Rule:    item.Rule,
+		}
+
+		var servers []dynamic.TCPServer
+		for range service.LoadBalancer.Servers {
+			servers = append(servers, dynamic.TCPServer{
+				Address: fmt.Sprintf("%s:%d", c.endpoint.Host, c.endpoint.WEB),
+			})
+		}
+
+		output.TCP.Services[name] = &dynamic.TCPService{
+			LoadBalancer: &dynamic.TCPServersLoadBalancer{Servers: servers},
+		}
+	}
+}
+
+func (c *Client) prepareUDPResponse(res, output *dynamic.Configuration) {
+	for key := range res.UDP.Routers {
+		if strings.HasSuffix(key, "@internal") {
+			continue
+		}
+
+		name := strings.Split(key, "@")[0]
+		name = fmt.Sprintf("%s-%s", name, c.endpoint.Host)
+
+		service, ok := res.UDP.Services[key]
+		if !ok || service.LoadBalancer == nil {
+			continue
+		}
+
+		if output.UDP == nil {
+			output.UDP = &dynamic.UDPConfiguration{
+				Routers:  make(map[string]*dynamic.UDPRouter),
+				Services: make(map[string]*dynamic.UDPService),
+			}
+		}
+
+		output.UDP.Routers[name] = &dynamic.UDPRouter{
+			Service: name,
+		}
+
+		output.UDP.Services[name] = &dynamic.UDPService{
+			LoadBalancer: &dynamic.UDPServersLoadBalancer{
+				Servers: []dynamic.UDPServer{
+					{Address: fmt.Sprintf("%s:%d", c.endpoint.Host, c.endpoint.WEB)},
+				},
+			},
+		}
+	}
+}
+
 func (c *Client) FetchRaw(ctx context.Context, out chan<- *dynamic.Configuration) error {
-	if res, err := c.httpCall(ctx); err != nil {
+	res, hash, err := c.httpCallWithBackoff(ctx)
+	if err != nil {
+		// Could not reach the upstream: keep serving the last known good
+		// configuration rather than clearing routes on a transient blip.
 		out <- nil
 
 		return err
-	} else if len(res.HTTP.Routers) > 0 && len(res.HTTP.Services) > 0 {
-		out <- c.prepareResponse(res)
+	}
 
+	if hash == c.lastHash {
+		// Upstream configuration hasn't changed since the last poll: skip
+		// re-marshalling and sending an identical dynamic.Configuration.
 		return nil
 	}
 
-	out <- nil
+	c.lastHash = hash
+
+	routers := len(res.HTTP.Routers) + len(res.TCP.Routers) + len(res.UDP.Routers)
+	services := len(res.HTTP.Services) + len(res.TCP.Services) + len(res.UDP.Services)
+
+	if routers == 0 || services == 0 {
+		// The upstream answered but currently serves nothing: emit an
+		// empty (non-nil) configuration so Provider.merge drops this
+		// host's previously aggregated routes instead of keeping them
+		// forever.
+		out <- &dynamic.Configuration{}
+
+		return fmt.Errorf("%w (1client:%q)", ErrEmptyResponse, c.endpoint.Host)
+	}
+
+	out <- c.prepareResponse(res)
 
-	return fmt.Errorf("%w (1client:%q)", ErrEmptyResponse, c.endpoint.Host)
+	return nil
 }
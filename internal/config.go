@@ -3,31 +3,53 @@ package internal
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 )
 
 type Endpoint struct {
-	Host string `json:"host"    yaml:"host"    toml:"host"    mapstructure:"host"`
-	API  int    `json:"apiPort" yaml:"apiPort" toml:"apiPort" mapstructure:"apiPort"`
-	WEB  int    `json:"webPort" yaml:"webPort" toml:"webPort" mapstructure:"webPort"`
-	TLS  *TLS   `json:"tls"     yaml:"tls"     toml:"tls"     mapstructure:"tls"`
+	Host        string        `json:"host"        yaml:"host"        toml:"host"        mapstructure:"host"`
+	API         int           `json:"apiPort"     yaml:"apiPort"     toml:"apiPort"     mapstructure:"apiPort"`
+	WEB         int           `json:"webPort"     yaml:"webPort"     toml:"webPort"     mapstructure:"webPort"`
+	TLS         *TLS          `json:"tls"         yaml:"tls"         toml:"tls"         mapstructure:"tls"`
+	HTTP        *HTTP         `json:"http"        yaml:"http"        toml:"http"        mapstructure:"http"`
+	PollTimeout time.Duration `json:"pollTimeout" yaml:"pollTimeout" toml:"pollTimeout" mapstructure:"pollTimeout"`
 }
 
 type TLS struct {
-	IgnoreInsecure bool `json:"ignoreInsecure" yaml:"ignoreInsecure" toml:"ignoreInsecure" mapstructure:"ignoreInsecure"`
+	IgnoreInsecure bool   `json:"ignoreInsecure" yaml:"ignoreInsecure" toml:"ignoreInsecure" mapstructure:"ignoreInsecure"`
+	CA             string `json:"ca"             yaml:"ca"             toml:"ca"             mapstructure:"ca"`
+	Cert           string `json:"cert"           yaml:"cert"           toml:"cert"           mapstructure:"cert"`
+	Key            string `json:"key"            yaml:"key"            toml:"key"            mapstructure:"key"`
+	ServerName     string `json:"serverName"     yaml:"serverName"     toml:"serverName"     mapstructure:"serverName"`
+}
+
+// HTTP holds transport-level settings that apply to every request a
+// Client makes against its endpoint, as opposed to TLS which only
+// configures the connection.
+type HTTP struct {
+	Headers map[string]string `json:"headers" yaml:"headers" toml:"headers" mapstructure:"headers"`
 }
 
 type Config struct {
-	ConnTimeout  time.Duration `json:"connTimeout"  yaml:"connTimeout"  toml:"connTimeout"  mapstructure:"connTimeout"`
-	PollInterval time.Duration `json:"pollInterval" yaml:"pollInterval" toml:"pollInterval" mapstructure:"pollInterval"`
-	Endpoints    []Endpoint    `json:"endpoints"    yaml:"endpoints"    toml:"endpoints"    mapstructure:"endpoints"`
-	TLSResolver  *string       `json:"tlsResolver"  yaml:"tlsResolver"  toml:"tlsResolver"  mapstructure:"tlsResolver"`
+	ConnTimeout         time.Duration        `json:"connTimeout"         yaml:"connTimeout"         toml:"connTimeout"         mapstructure:"connTimeout"`
+	PollInterval        time.Duration        `json:"pollInterval"        yaml:"pollInterval"        toml:"pollInterval"        mapstructure:"pollInterval"`
+	Endpoints           []Endpoint           `json:"endpoints"           yaml:"endpoints"           toml:"endpoints"           mapstructure:"endpoints"`
+	TLSResolver         *string              `json:"tlsResolver"         yaml:"tlsResolver"         toml:"tlsResolver"         mapstructure:"tlsResolver"`
+	KubernetesDiscovery *KubernetesDiscovery `json:"kubernetesDiscovery" yaml:"kubernetesDiscovery" toml:"kubernetesDiscovery" mapstructure:"kubernetesDiscovery"`
 }
 
+const (
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultExpectContinueTimeout = 1 * time.Second
+)
+
 const defaultPath = "/"
 
 func (c *Config) Validate() error {
@@ -43,10 +65,14 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("wrong poll interval: %s", c.PollInterval)
 	}
 
-	if len(c.Endpoints) == 0 {
+	if len(c.Endpoints) == 0 && c.KubernetesDiscovery == nil {
 		return errors.New("empty endpoints")
 	}
 
+	if err := c.KubernetesDiscovery.Validate(); err != nil {
+		return err
+	}
+
 	for i, endpoint := range c.Endpoints {
 		if endpoint.Host == "" {
 			return fmt.Errorf("empty #%d endpoint host", i)
@@ -59,6 +85,10 @@ func (c *Config) Validate() error {
 		if endpoint.WEB <= 0 {
 			return fmt.Errorf("empty #%d endpoint webPort: %d", i, endpoint.WEB)
 		}
+
+		if endpoint.TLS != nil && (endpoint.TLS.Cert == "") != (endpoint.TLS.Key == "") {
+			return fmt.Errorf("#%d endpoint tls: cert and key must be set together", i)
+		}
 	}
 
 	return nil
@@ -78,20 +108,78 @@ func (e Endpoint) buildURI(port int, path string) string {
 	return uri.String()
 }
 
+func (e *TLS) clientConfig() (*tls.Config, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	// #nosec G402 -- InsecureSkipVerify is opt-in via ignoreInsecure.
+	cfg := &tls.Config{
+		InsecureSkipVerify: e.IgnoreInsecure,
+		ServerName:         e.ServerName,
+	}
+
+	if e.CA != "" {
+		pem, err := os.ReadFile(e.CA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle %s: %w", e.CA, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("could not parse CA bundle %s", e.CA)
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if e.Cert != "" || e.Key != "" {
+		cert, err := tls.LoadX509KeyPair(e.Cert, e.Key)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client cert/key (%s, %s): %w", e.Cert, e.Key, err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
 func (c *Config) PrepareClients(top context.Context) ([]*Client, error) {
+	return c.prepareClientsFor(top, c.Endpoints)
+}
+
+// prepareClientsFor builds a Client per endpoint, the same way
+// PrepareClients does for c.Endpoints. It also backs the Kubernetes
+// discovery path, where the endpoint list is rebuilt from the pods
+// behind a Service instead of being static.
+func (c *Config) prepareClientsFor(top context.Context, endpoints []Endpoint) ([]*Client, error) {
 	ctx, cancel := context.WithTimeout(top, c.ConnTimeout)
 	defer cancel()
 
-	out := make([]*Client, 0, len(c.Endpoints))
-	for _, endpoint := range c.Endpoints {
-		cli := new(http.Client)
-		if endpoint.TLS != nil && endpoint.TLS.IgnoreInsecure {
-			cli.Transport = &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402
-			}
+	out := make([]*Client, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		tlsConfig, err := endpoint.TLS.clientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("could not prepare TLS for endpoint %s: %w", endpoint.Host, err)
+		}
+
+		cli := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+				DialContext: (&net.Dialer{
+					Timeout: c.ConnTimeout,
+				}).DialContext,
+				IdleConnTimeout:       defaultIdleConnTimeout,
+				ExpectContinueTimeout: defaultExpectContinueTimeout,
+			},
+		}
+
+		var headers map[string]string
+		if endpoint.HTTP != nil {
+			headers = endpoint.HTTP.Headers
 		}
 
-		var err error
 		for _, port := range []int{endpoint.API, endpoint.WEB} {
 			uri := endpoint.buildURI(port, defaultPath)
 
@@ -100,6 +188,10 @@ func (c *Config) PrepareClients(top context.Context) ([]*Client, error) {
 				return nil, fmt.Errorf("could not prepare request(%s): %w", uri, err)
 			}
 
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+
 			var res *http.Response
 			if res, err = cli.Do(req); err != nil {
 				return nil, fmt.Errorf("could not call request(%s): %w", uri, err)
@@ -110,10 +202,17 @@ func (c *Config) PrepareClients(top context.Context) ([]*Client, error) {
 			}
 		}
 
+		pollTimeout := endpoint.PollTimeout
+		if pollTimeout <= 0 {
+			pollTimeout = c.ConnTimeout
+		}
+
 		out = append(out, &Client{
-			Client:   cli,
-			endpoint: endpoint,
-			resolver: c.TLSResolver,
+			Client:      cli,
+			endpoint:    endpoint,
+			resolver:    c.TLSResolver,
+			headers:     headers,
+			pollTimeout: pollTimeout,
 		})
 	}
 
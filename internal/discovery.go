@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// KubernetesDiscovery materializes one Endpoint per ready pod IP behind a
+// Kubernetes Service, as an alternative to a static Endpoints list. It is
+// meant for autoscaling Traefik-of-Traefiks topologies where the set of
+// upstream instances changes at runtime.
+type KubernetesDiscovery struct {
+	Namespace     string        `json:"namespace"     yaml:"namespace"     toml:"namespace"     mapstructure:"namespace"`
+	ServiceName   string        `json:"serviceName"   yaml:"serviceName"   toml:"serviceName"   mapstructure:"serviceName"`
+	LabelSelector string        `json:"labelSelector" yaml:"labelSelector" toml:"labelSelector" mapstructure:"labelSelector"`
+	APIPortName   string        `json:"apiPortName"   yaml:"apiPortName"   toml:"apiPortName"   mapstructure:"apiPortName"`
+	WebPortName   string        `json:"webPortName"   yaml:"webPortName"   toml:"webPortName"   mapstructure:"webPortName"`
+	TLS           *TLS          `json:"tls"           yaml:"tls"           toml:"tls"           mapstructure:"tls"`
+	HTTP          *HTTP         `json:"http"          yaml:"http"          toml:"http"          mapstructure:"http"`
+	PollTimeout   time.Duration `json:"pollTimeout"   yaml:"pollTimeout"   toml:"pollTimeout"   mapstructure:"pollTimeout"`
+}
+
+func (k *KubernetesDiscovery) Validate() error {
+	if k == nil {
+		return nil
+	}
+
+	if k.ServiceName == "" {
+		return errors.New("empty kubernetesDiscovery serviceName")
+	}
+
+	if k.APIPortName == "" {
+		return errors.New("empty kubernetesDiscovery apiPortName")
+	}
+
+	if k.WebPortName == "" {
+		return errors.New("empty kubernetesDiscovery webPortName")
+	}
+
+	return nil
+}
+
+// discoveryDebounce coalesces the burst of Add/Update/Delete events that
+// fires for every pod when a Deployment scales, so a scale-out of N pods
+// produces one onChange call instead of N.
+const discoveryDebounce = 500 * time.Millisecond
+
+// Watch follows the ready pod IPs behind k's Service via EndpointSlices
+// and calls onChange with the current set of Endpoint every time
+// membership settles. It blocks until ctx is done.
+func (k *KubernetesDiscovery) Watch(ctx context.Context, onChange func([]Endpoint)) error {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("could not load in-cluster config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("could not build kubernetes client: %w", err)
+	}
+
+	selector := fmt.Sprintf("kubernetes.io/service-name=%s", k.ServiceName)
+	if k.LabelSelector != "" {
+		selector += "," + k.LabelSelector
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		clientset,
+		0,
+		informers.WithNamespace(k.Namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector
+		}),
+	)
+
+	informer := factory.Discovery().V1().EndpointSlices().Informer()
+
+	var timer *time.Timer
+
+	debounce := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+
+		timer = time.AfterFunc(discoveryDebounce, func() {
+			onChange(k.endpoints(informer.GetStore().List()))
+		})
+	}
+
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { debounce() },
+		UpdateFunc: func(interface{}, interface{}) { debounce() },
+		DeleteFunc: func(interface{}) { debounce() },
+	})
+	if err != nil {
+		return fmt.Errorf("could not watch endpointslices for %s: %w", k.ServiceName, err)
+	}
+
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	<-ctx.Done()
+
+	if timer != nil {
+		timer.Stop()
+	}
+
+	return nil
+}
+
+// endpoints builds the Endpoint list from the informer's current
+// EndpointSlice store, skipping pods that aren't ready or don't expose
+// both named ports.
+func (k *KubernetesDiscovery) endpoints(objs []interface{}) []Endpoint {
+	var out []Endpoint
+
+	for _, obj := range objs {
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			continue
+		}
+
+		apiPort, apiOK := k.portFor(slice, k.APIPortName)
+		webPort, webOK := k.portFor(slice, k.WebPortName)
+
+		if !apiOK || !webOK {
+			continue
+		}
+
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready == nil || !*ep.Conditions.Ready {
+				continue
+			}
+
+			for _, addr := range ep.Addresses {
+				out = append(out, Endpoint{
+					Host:        addr,
+					API:         apiPort,
+					WEB:         webPort,
+					TLS:         k.TLS,
+					HTTP:        k.HTTP,
+					PollTimeout: k.PollTimeout,
+				})
+			}
+		}
+	}
+
+	return out
+}
+
+func (k *KubernetesDiscovery) portFor(slice *discoveryv1.EndpointSlice, name string) (int, bool) {
+	for _, port := range slice.Ports {
+		if port.Name != nil && *port.Name == name && port.Port != nil {
+			return int(*port.Port), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/traefik/genconf/dynamic"
+)
+
+// mergeDebounce bounds how long the Provider waits for more updates from
+// other clients before emitting a merged configuration, so that N
+// endpoints reconciling within a few milliseconds of each other produce
+// one update downstream instead of N.
+const mergeDebounce = 50 * time.Millisecond
+
+// Provider polls a set of Clients on PollInterval ticks and emits one
+// merged dynamic.Configuration downstream, aggregating each endpoint's
+// HTTP/TCP/UDP contribution.
+type Provider struct {
+	clients      []*Client
+	pollInterval time.Duration
+}
+
+func NewProvider(clients []*Client, pollInterval time.Duration) *Provider {
+	return &Provider{
+		clients:      clients,
+		pollInterval: pollInterval,
+	}
+}
+
+type update struct {
+	host string
+	cfg  *dynamic.Configuration
+}
+
+// Provide polls every client on its own goroutine and sends a merged
+// dynamic.Configuration to out each time the aggregate changes. It
+// blocks until ctx is done, then waits for its pollers to stop before
+// returning.
+func (p *Provider) Provide(ctx context.Context, out chan<- *dynamic.Configuration) error {
+	if len(p.clients) == 0 {
+		return errors.New("no clients to poll")
+	}
+
+	raw := make(chan update)
+
+	var wg sync.WaitGroup
+	for _, cli := range p.clients {
+		wg.Add(1)
+
+		go func(cli *Client) {
+			defer wg.Done()
+
+			p.pollClient(ctx, cli, raw)
+		}(cli)
+	}
+
+	go func() {
+		wg.Wait()
+		close(raw)
+	}()
+
+	p.merge(ctx, raw, out)
+	wg.Wait()
+
+	return nil
+}
+
+func (p *Provider) pollClient(ctx context.Context, cli *Client, raw chan<- update) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	local := make(chan *dynamic.Configuration, 1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = cli.FetchRaw(ctx, local)
+
+			select {
+			case cfg := <-local:
+				if cfg == nil {
+					continue
+				}
+
+				select {
+				case raw <- update{host: cli.Endpoint(), cfg: cfg}:
+				case <-ctx.Done():
+					return
+				}
+			default:
+				// FetchRaw skipped the send: the upstream config is unchanged.
+			}
+		}
+	}
+}
+
+func (p *Provider) merge(ctx context.Context, raw <-chan update, out chan<- *dynamic.Configuration) {
+	latest := make(map[string]*dynamic.Configuration, len(p.clients))
+
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+
+			return
+		case u, ok := <-raw:
+			if !ok {
+				return
+			}
+
+			latest[u.host] = u.cfg
+
+			if timer == nil {
+				timer = time.NewTimer(mergeDebounce)
+				timerC = timer.C
+
+				continue
+			}
+
+			if !timer.Stop() {
+				<-timer.C
+			}
+
+			timer.Reset(mergeDebounce)
+		case <-timerC:
+			timer = nil
+			timerC = nil
+
+			select {
+			case out <- mergeConfigurations(latest):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func mergeConfigurations(latest map[string]*dynamic.Configuration) *dynamic.Configuration {
+	var out dynamic.Configuration
+
+	for _, cfg := range latest {
+		if cfg == nil {
+			continue
+		}
+
+		if cfg.HTTP != nil {
+			if out.HTTP == nil {
+				out.HTTP = &dynamic.HTTPConfiguration{
+					Routers:     make(map[string]*dynamic.Router),
+					Services:    make(map[string]*dynamic.Service),
+					Middlewares: make(map[string]*dynamic.Middleware),
+				}
+			}
+
+			for k, v := range cfg.HTTP.Routers {
+				out.HTTP.Routers[k] = v
+			}
+
+			for k, v := range cfg.HTTP.Services {
+				out.HTTP.Services[k] = v
+			}
+
+			for k, v := range cfg.HTTP.Middlewares {
+				out.HTTP.Middlewares[k] = v
+			}
+		}
+
+		if cfg.TCP != nil {
+			if out.TCP == nil {
+				out.TCP = &dynamic.TCPConfiguration{
+					Routers:  make(map[string]*dynamic.TCPRouter),
+					Services: make(map[string]*dynamic.TCPService),
+				}
+			}
+
+			for k, v := range cfg.TCP.Routers {
+				out.TCP.Routers[k] = v
+			}
+
+			for k, v := range cfg.TCP.Services {
+				out.TCP.Services[k] = v
+			}
+		}
+
+		if cfg.UDP != nil {
+			if out.UDP == nil {
+				out.UDP = &dynamic.UDPConfiguration{
+					Routers:  make(map[string]*dynamic.UDPRouter),
+					Services: make(map[string]*dynamic.UDPService),
+				}
+			}
+
+			for k, v := range cfg.UDP.Routers {
+				out.UDP.Routers[k] = v
+			}
+
+			for k, v := range cfg.UDP.Services {
+				out.UDP.Services[k] = v
+			}
+		}
+	}
+
+	return &out
+}
+
+// Provide builds this Config's Clients and runs a Provider over them
+// until ctx is done. With a static c.Endpoints list this is a thin
+// wrapper around PrepareClients/NewProvider; with c.KubernetesDiscovery
+// set, it instead watches the discovered pod set and restarts the
+// Provider on a fresh client list every time membership changes.
+func (c *Config) Provide(ctx context.Context, out chan<- *dynamic.Configuration) error {
+	if c.KubernetesDiscovery == nil {
+		clients, err := c.PrepareClients(ctx)
+		if err != nil {
+			return err
+		}
+
+		return NewProvider(clients, c.PollInterval).Provide(ctx, out)
+	}
+
+	errs := make(chan error, 1)
+
+	var (
+		mu     sync.Mutex
+		cancel context.CancelFunc
+	)
+
+	restart := func(endpoints []Endpoint) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if cancel != nil {
+			cancel()
+		}
+
+		if len(endpoints) == 0 {
+			// No ready pods yet (or the Service just scaled to zero): leave
+			// the previous Provider stopped and wait for the next change.
+			return
+		}
+
+		clients, err := c.prepareClientsFor(ctx, endpoints)
+		if err != nil {
+			errs <- fmt.Errorf("could not prepare discovered clients: %w", err)
+
+			return
+		}
+
+		var pollCtx context.Context
+		pollCtx, cancel = context.WithCancel(ctx)
+
+		go func() {
+			if err := NewProvider(clients, c.PollInterval).Provide(pollCtx, out); err != nil && pollCtx.Err() == nil {
+				errs <- err
+			}
+		}()
+	}
+
+	go func() {
+		if err := c.KubernetesDiscovery.Watch(ctx, restart); err != nil {
+			errs <- fmt.Errorf("could not watch kubernetes discovery: %w", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return err
+	}
+}